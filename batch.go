@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	batchMaxQueries  = 500
+	batchWorkerLimit = 16
+	batchDeadline    = 60 * time.Second
+)
+
+type batchResult struct {
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// batchSearchHandler resolves many queries concurrently behind a bounded
+// worker pool and a per-batch deadline, reusing the same cache +
+// singleflight pipeline as searchHandler via resolveQuery.
+func (app *App) batchSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var queries []string
+	if err := json.NewDecoder(r.Body).Decode(&queries); err != nil {
+		app.jsonError(w, "Request body must be a JSON array of query strings", http.StatusBadRequest)
+		return
+	}
+
+	if len(queries) == 0 {
+		app.jsonError(w, "At least one query is required", http.StatusBadRequest)
+		return
+	}
+	if len(queries) > batchMaxQueries {
+		app.jsonError(w, fmt.Sprintf("At most %d queries are allowed per batch", batchMaxQueries), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), batchDeadline)
+	defer cancel()
+
+	log := app.logger.With(slog.Int("batch_size", len(queries)), slog.String("request_id", middleware.GetReqID(r.Context())))
+	log.Info("Received batch search request")
+
+	results := make(map[string]batchResult, len(queries))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, batchWorkerLimit)
+
+	for _, query := range queries {
+		if query == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				mu.Lock()
+				results[query] = batchResult{Error: "batch deadline exceeded"}
+				mu.Unlock()
+				return
+			}
+			defer func() { <-sem }()
+
+			finalURL, err := app.resolveQuery(ctx, query, log.With(slog.String("query", query)))
+
+			mu.Lock()
+			if err != nil {
+				results[query] = batchResult{Error: err.Error()}
+			} else {
+				results[query] = batchResult{URL: finalURL}
+			}
+			mu.Unlock()
+		}(query)
+	}
+
+	wg.Wait()
+	log.Info("Batch search request completed")
+	app.jsonResponse(w, results, http.StatusOK)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	envCacheBackend  = "GETMUSIC_CACHE_BACKEND"
+	envCacheMaxItems = "GETMUSIC_CACHE_MAX_ITEMS"
+	envRedisAddr     = "GETMUSIC_REDIS_ADDR"
+	defaultMaxItems  = 10000
+)
+
+// Cache abstracts the query -> resolved URL lookup so searchHandler works
+// unchanged whether results are kept in-process or in Redis.
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key string, value string, ttl time.Duration)
+}
+
+// CacheStats are Prometheus-style counters; exposed by backends that track
+// them via the CacheStatsProvider interface.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// CacheStatsProvider is implemented by cache backends that track hit/miss/
+// eviction counters. Not every backend has to: it's queried with a type
+// assertion in metricsHandler rather than added to Cache itself.
+type CacheStatsProvider interface {
+	Stats() CacheStats
+}
+
+// NewCacheFromEnv selects and constructs the configured cache backend.
+// GETMUSIC_CACHE_BACKEND=redis switches to Redis (GETMUSIC_REDIS_ADDR,
+// default "localhost:6379"); anything else, including unset, uses the
+// bounded in-memory LRU (GETMUSIC_CACHE_MAX_ITEMS, default 10000).
+func NewCacheFromEnv(logger *slog.Logger) Cache {
+	if os.Getenv(envCacheBackend) == "redis" {
+		addr := os.Getenv(envRedisAddr)
+		if addr == "" {
+			addr = "localhost:6379"
+		}
+		return NewRedisCache(addr, logger)
+	}
+
+	maxItems := defaultMaxItems
+	if raw := os.Getenv(envCacheMaxItems); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			maxItems = n
+		}
+	}
+	return NewMemoryCache(maxItems)
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+// MemoryCache is a bounded, TTL-evicting LRU: container/list tracks recency
+// order so once maxItems is exceeded the least-recently-used entry is
+// dropped, and a background ticker sweeps expired entries.
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxItems int
+	items    map[string]*list.Element
+	order    *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func NewMemoryCache(maxItems int) *MemoryCache {
+	if maxItems <= 0 {
+		maxItems = defaultMaxItems
+	}
+	c := &MemoryCache{
+		maxItems: maxItems,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+	go c.evictExpiredLoop()
+	return c
+}
+
+func (c *MemoryCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.items[key]
+	if !found {
+		atomic.AddUint64(&c.misses, 1)
+		return "", false
+	}
+
+	entry := el.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElementLocked(el)
+		atomic.AddUint64(&c.misses, 1)
+		atomic.AddUint64(&c.evictions, 1)
+		return "", false
+	}
+
+	c.order.MoveToFront(el)
+	atomic.AddUint64(&c.hits, 1)
+	return entry.value, true
+}
+
+func (c *MemoryCache) Set(key string, value string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, found := c.items[key]; found {
+		entry := el.Value.(*memoryCacheEntry)
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&memoryCacheEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	c.items[key] = el
+
+	if c.order.Len() > c.maxItems {
+		c.evictOldestLocked()
+	}
+}
+
+func (c *MemoryCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+	}
+}
+
+func (c *MemoryCache) evictOldestLocked() {
+	oldest := c.order.Back()
+	if oldest == nil {
+		return
+	}
+	c.removeElementLocked(oldest)
+	atomic.AddUint64(&c.evictions, 1)
+}
+
+func (c *MemoryCache) removeElementLocked(el *list.Element) {
+	entry := el.Value.(*memoryCacheEntry)
+	delete(c.items, entry.key)
+	c.order.Remove(el)
+}
+
+func (c *MemoryCache) evictExpiredLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.sweepExpired()
+	}
+}
+
+func (c *MemoryCache) sweepExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if now.After(el.Value.(*memoryCacheEntry).expiresAt) {
+			c.removeElementLocked(el)
+			atomic.AddUint64(&c.evictions, 1)
+		}
+		el = next
+	}
+}
+
+// RedisCache stores query -> URL in Redis with a native key TTL.
+type RedisCache struct {
+	client *redis.Client
+	logger *slog.Logger
+	hits   uint64
+	misses uint64
+}
+
+// NewRedisCache pings the given address once so a misconfigured
+// GETMUSIC_REDIS_ADDR is visible in the logs at startup instead of surfacing
+// later as an app that silently never caches anything.
+func NewRedisCache(addr string, logger *slog.Logger) *RedisCache {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		logger.Error("Redis cache backend unreachable", "addr", addr, "error", err)
+	}
+	return &RedisCache{client: client, logger: logger}
+}
+
+func (c *RedisCache) Get(key string) (string, bool) {
+	val, err := c.client.Get(context.Background(), key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.logger.Warn("Redis cache get failed", "key", key, "error", err)
+		}
+		atomic.AddUint64(&c.misses, 1)
+		return "", false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return val, true
+}
+
+func (c *RedisCache) Set(key string, value string, ttl time.Duration) {
+	if err := c.client.Set(context.Background(), key, value, ttl).Err(); err != nil {
+		c.logger.Error("Redis cache set failed", "key", key, "error", err)
+	}
+}
+
+// Stats reports hits/misses only: Redis applies EXPIRE evictions internally
+// and doesn't surface a per-key eviction count worth tracking here.
+func (c *RedisCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+func (app *App) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	provider, ok := app.cache.(CacheStatsProvider)
+	if !ok {
+		return
+	}
+	stats := provider.Stats()
+
+	fmt.Fprintf(w, "# HELP getmusic_cache_hits_total Cache lookups that found a cached URL.\n")
+	fmt.Fprintf(w, "# TYPE getmusic_cache_hits_total counter\n")
+	fmt.Fprintf(w, "getmusic_cache_hits_total %d\n", stats.Hits)
+	fmt.Fprintf(w, "# HELP getmusic_cache_misses_total Cache lookups that found nothing.\n")
+	fmt.Fprintf(w, "# TYPE getmusic_cache_misses_total counter\n")
+	fmt.Fprintf(w, "getmusic_cache_misses_total %d\n", stats.Misses)
+	fmt.Fprintf(w, "# HELP getmusic_cache_evictions_total Entries dropped for exceeding the size limit or their TTL.\n")
+	fmt.Fprintf(w, "# TYPE getmusic_cache_evictions_total counter\n")
+	fmt.Fprintf(w, "getmusic_cache_evictions_total %d\n", stats.Evictions)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheGetSet(t *testing.T) {
+	c := NewMemoryCache(10)
+
+	if _, found := c.Get("missing"); found {
+		t.Fatal("expected miss for unset key")
+	}
+
+	c.Set("a", "url-a", time.Minute)
+	val, found := c.Get("a")
+	if !found || val != "url-a" {
+		t.Fatalf("got (%q, %v), want (%q, true)", val, found, "url-a")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("got stats %+v, want 1 hit and 1 miss", stats)
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	c := NewMemoryCache(10)
+
+	c.Set("a", "url-a", -time.Second)
+	if _, found := c.Get("a"); found {
+		t.Fatal("expected already-expired entry to miss")
+	}
+
+	stats := c.Stats()
+	if stats.Evictions != 1 {
+		t.Fatalf("got evictions %d, want 1", stats.Evictions)
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(2)
+
+	c.Set("a", "url-a", time.Minute)
+	c.Set("b", "url-b", time.Minute)
+	if _, found := c.Get("a"); !found {
+		t.Fatal("expected a to still be cached")
+	}
+
+	// a was just touched, so filling a third slot should evict b, not a.
+	c.Set("c", "url-c", time.Minute)
+
+	if _, found := c.Get("a"); !found {
+		t.Fatal("expected recently-used a to survive eviction")
+	}
+	if _, found := c.Get("b"); found {
+		t.Fatal("expected least-recently-used b to be evicted")
+	}
+	if _, found := c.Get("c"); !found {
+		t.Fatal("expected newly-set c to be cached")
+	}
+}
+
+func TestMemoryCacheSetOverwritesExisting(t *testing.T) {
+	c := NewMemoryCache(10)
+
+	c.Set("a", "url-1", time.Minute)
+	c.Set("a", "url-2", time.Minute)
+
+	val, found := c.Get("a")
+	if !found || val != "url-2" {
+		t.Fatalf("got (%q, %v), want (%q, true)", val, found, "url-2")
+	}
+}
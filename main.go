@@ -4,10 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"sync"
@@ -30,6 +28,7 @@ const (
 type TrackItem struct {
 	ID           int    `json:"id"`
 	AudioQuality string `json:"audioQuality"`
+	Provider     string `json:"-"`
 }
 
 type SearchResponse struct {
@@ -41,56 +40,21 @@ type TrackURLItem struct {
 }
 
 type FinalResponse struct {
-	URL string `json:"url"`
+	URL       string            `json:"url,omitempty"`
+	Quality   string            `json:"quality,omitempty"`
+	Qualities map[string]string `json:"qualities,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
-type cacheItem struct {
-	value     string
-	expiresAt time.Time
-}
-
-type Cache struct {
-	mu    sync.RWMutex
-	items map[string]cacheItem
-}
-
-func NewCache() *Cache {
-	return &Cache{
-		items: make(map[string]cacheItem),
-	}
-}
-
-func (c *Cache) Get(key string) (string, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	item, found := c.items[key]
-	if !found {
-		return "", false
-	}
-	if time.Now().After(item.expiresAt) {
-		return "", false
-	}
-	return item.value, true
-}
-
-func (c *Cache) Set(key string, value string, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items[key] = cacheItem{
-		value:     value,
-		expiresAt: time.Now().Add(ttl),
-	}
-}
-
 type App struct {
-	logger *slog.Logger
-	client *http.Client
-	cache  *Cache
-	sf     *singleflight.Group
+	logger    *slog.Logger
+	client    *http.Client
+	cache     Cache
+	sf        *singleflight.Group
+	providers *ProviderRegistry
 }
 
 func main() {
@@ -99,10 +63,11 @@ func main() {
 		Timeout: httpTimeout,
 	}
 	app := &App{
-		logger: logger,
-		client: httpClient,
-		cache:  NewCache(),
-		sf:     &singleflight.Group{},
+		logger:    logger,
+		client:    httpClient,
+		cache:     NewCacheFromEnv(logger),
+		sf:        &singleflight.Group{},
+		providers: NewProviderRegistry(httpClient, logger),
 	}
 
 	r := chi.NewRouter()
@@ -112,6 +77,18 @@ func main() {
 	r.Use(middleware.Recoverer)
 
 	r.Get("/search/{query}", app.searchHandler)
+	r.Get("/metrics", app.metricsHandler)
+	r.Get("/stream/{query}", app.streamHandler)
+	r.Get("/stream/id/{id}", app.streamByIDHandler)
+	r.Post("/search", app.batchSearchHandler)
+
+	r.Route("/rest", func(r chi.Router) {
+		r.Get("/ping.view", app.subsonicPingHandler)
+		r.Get("/search3.view", app.subsonicSearch3Handler)
+		r.Get("/getSong.view", app.subsonicGetSongHandler)
+		r.Get("/stream.view", app.subsonicStreamHandler)
+		r.Get("/download.view", app.subsonicDownloadHandler)
+	})
 
 	server := &http.Server{
 		Addr:    serverPort,
@@ -151,20 +128,44 @@ func (app *App) searchHandler(w http.ResponseWriter, r *http.Request) {
 	log := app.logger.With(slog.String("query", query), slog.String("request_id", middleware.GetReqID(r.Context())))
 	log.Info("Received search request")
 
+	if r.URL.Query().Has("quality") || r.URL.Query().Has("all") {
+		app.qualityAwareSearchHandler(w, r, query, log)
+		return
+	}
+
+	finalURL, err := app.resolveQuery(r.Context(), query, log)
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			log.Warn("Failed to find track URL", "error", statusErr.Error())
+			app.jsonError(w, statusErr.Error(), statusErr.Code)
+		} else {
+			log.Error("Internal error during singleflight execution", "error", err)
+			app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.jsonResponse(w, FinalResponse{URL: finalURL}, http.StatusOK)
+}
+
+// resolveQuery is the single-query resolution pipeline: cache check,
+// singleflight-deduped search + fan-out resolve, then cache the winner.
+// It backs both searchHandler and batchSearchHandler.
+func (app *App) resolveQuery(ctx context.Context, query string, log *slog.Logger) (string, error) {
 	if cachedURL, found := app.cache.Get(query); found {
 		log.Info("Cache hit")
-		app.jsonResponse(w, FinalResponse{URL: cachedURL}, http.StatusOK)
-		return
+		return cachedURL, nil
 	}
 	log.Info("Cache miss")
 
 	v, err, _ := app.sf.Do(query, func() (interface{}, error) {
-		tracks, err := app.searchTracks(r.Context(), query)
+		tracks, err := app.searchTracks(ctx, query)
 		if err != nil {
 			return nil, err
 		}
 
-		finalURL, err := app.findFirstValidTrackURL(r.Context(), tracks, log)
+		finalURL, err := app.findFirstValidTrackURL(ctx, tracks, log)
 		if err != nil {
 			return nil, err
 		}
@@ -173,21 +174,11 @@ func (app *App) searchHandler(w http.ResponseWriter, r *http.Request) {
 		log.Info("Result cached", "ttl", cacheTTL.String())
 		return finalURL, nil
 	})
-
 	if err != nil {
-		var statusErr *statusError
-		if errors.As(err, &statusErr) {
-			log.Warn("Failed to find track URL", "error", statusErr.Error())
-			app.jsonError(w, statusErr.Error(), statusErr.Code)
-		} else {
-			log.Error("Internal error during singleflight execution", "error", err)
-			app.jsonError(w, "Internal server error", http.StatusInternalServerError)
-		}
-		return
+		return "", err
 	}
 
-	finalURL := v.(string)
-	app.jsonResponse(w, FinalResponse{URL: finalURL}, http.StatusOK)
+	return v.(string), nil
 }
 
 func (app *App) findFirstValidTrackURL(ctx context.Context, tracks []TrackItem, log *slog.Logger) (string, error) {
@@ -209,7 +200,7 @@ func (app *App) findFirstValidTrackURL(ctx context.Context, tracks []TrackItem,
 			default:
 			}
 
-			finalURL, err := app.getTrackURL(ctx, track.ID, track.AudioQuality)
+			finalURL, err := app.getTrackURL(ctx, track)
 			if err != nil {
 				var statusErr *statusError
 				if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
@@ -245,74 +236,23 @@ func (app *App) findFirstValidTrackURL(ctx context.Context, tracks []TrackItem,
 	}
 }
 
+// searchTracks delegates to the provider registry, which tries each
+// configured upstream in turn until one returns results or reports the
+// track as not found.
 func (app *App) searchTracks(ctx context.Context, query string) ([]TrackItem, error) {
-	encodedQuery := url.QueryEscape(query)
-	reqURL := fmt.Sprintf("%s?s=%s", searchAPIURL, encodedQuery)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create search request: %w", err)
-	}
-
-	resp, err := app.client.Do(req)
-	if err != nil {
-		return nil, &statusError{http.StatusBadGateway, fmt.Errorf("search API request failed: %w", err)}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, &statusError{http.StatusBadGateway, fmt.Errorf("search API returned non-200 status: %d", resp.StatusCode)}
-	}
-
-	var searchResp SearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
-		return nil, fmt.Errorf("failed to decode search response: %w", err)
-	}
-
-	if len(searchResp.Items) == 0 {
-		return nil, &statusError{http.StatusNotFound, errors.New("track not found")}
-	}
-
-	return searchResp.Items, nil
+	return app.providers.Search(ctx, query)
 }
 
-func (app *App) getTrackURL(ctx context.Context, id int, quality string) (string, error) {
-	reqURL := fmt.Sprintf("%s?id=%d&quality=%s", trackAPIURL, id, quality)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create track URL request: %w", err)
-	}
-
-	resp, err := app.client.Do(req)
-	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			return "", err
-		}
-		return "", &statusError{http.StatusBadGateway, fmt.Errorf("track URL API request failed: %w", err)}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return "", &statusError{http.StatusNotFound, fmt.Errorf("upstream API returned 404 for track ID %d", id)}
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", &statusError{http.StatusBadGateway, fmt.Errorf("track URL API returned non-200 status: %d", resp.StatusCode)}
-	}
-
-	var trackInfo []TrackURLItem
-	if err := json.NewDecoder(resp.Body).Decode(&trackInfo); err != nil {
-		return "", fmt.Errorf("failed to decode track URL response: %w", err)
+// getTrackURL resolves a track to a playable URL. When the track carries a
+// Provider (set by searchTracks/the registry), resolution is pinned to that
+// provider; otherwise every provider is tried in order, which is the case
+// for callers that only ever see a bare track ID (e.g. the Subsonic
+// surface).
+func (app *App) getTrackURL(ctx context.Context, track TrackItem) (string, error) {
+	if track.Provider != "" {
+		return app.providers.ResolveOn(ctx, track.Provider, track.ID, track.AudioQuality)
 	}
-
-	for _, item := range trackInfo {
-		if item.OriginalTrackURL != "" {
-			return item.OriginalTrackURL, nil
-		}
-	}
-
-	return "", &statusError{http.StatusBadGateway, errors.New("could not find OriginalTrackUrl in upstream API response")}
+	return app.providers.Resolve(ctx, track.ID, track.AudioQuality)
 }
 
 type statusError struct {
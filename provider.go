@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Provider is an upstream backend capable of turning a search query into
+// candidate tracks and a candidate track into a playable URL.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query string) ([]TrackItem, error)
+	Resolve(ctx context.Context, id int, quality string) (string, error)
+}
+
+// tidalProvider talks to a tidal-api.binimum.org-shaped endpoint.
+type tidalProvider struct {
+	name         string
+	client       *http.Client
+	searchAPIURL string
+	trackAPIURL  string
+}
+
+func newTidalProvider(name string, client *http.Client, searchAPIURL, trackAPIURL string) *tidalProvider {
+	return &tidalProvider{name: name, client: client, searchAPIURL: searchAPIURL, trackAPIURL: trackAPIURL}
+}
+
+func (p *tidalProvider) Name() string { return p.name }
+
+func (p *tidalProvider) Search(ctx context.Context, query string) ([]TrackItem, error) {
+	encodedQuery := url.QueryEscape(query)
+	reqURL := fmt.Sprintf("%s?s=%s", p.searchAPIURL, encodedQuery)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create search request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, &statusError{http.StatusBadGateway, fmt.Errorf("search API request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{http.StatusBadGateway, fmt.Errorf("search API returned non-200 status: %d", resp.StatusCode)}
+	}
+
+	var searchResp SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	if len(searchResp.Items) == 0 {
+		return nil, &statusError{http.StatusNotFound, errors.New("track not found")}
+	}
+
+	return searchResp.Items, nil
+}
+
+func (p *tidalProvider) Resolve(ctx context.Context, id int, quality string) (string, error) {
+	reqURL := fmt.Sprintf("%s?id=%d&quality=%s", p.trackAPIURL, id, quality)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create track URL request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return "", err
+		}
+		return "", &statusError{http.StatusBadGateway, fmt.Errorf("track URL API request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", &statusError{http.StatusNotFound, fmt.Errorf("upstream API returned 404 for track ID %d", id)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{http.StatusBadGateway, fmt.Errorf("track URL API returned non-200 status: %d", resp.StatusCode)}
+	}
+
+	var trackInfo []TrackURLItem
+	if err := json.NewDecoder(resp.Body).Decode(&trackInfo); err != nil {
+		return "", fmt.Errorf("failed to decode track URL response: %w", err)
+	}
+
+	for _, item := range trackInfo {
+		if item.OriginalTrackURL != "" {
+			return item.OriginalTrackURL, nil
+		}
+	}
+
+	return "", &statusError{http.StatusBadGateway, errors.New("could not find OriginalTrackUrl in upstream API response")}
+}
+
+const (
+	// envProviders opts into failover: with it unset, NewProviderRegistry
+	// configures only defaultProviderName and a dead upstream means no
+	// search/resolve can succeed, since there is no safe default second
+	// upstream to fail over to without disclosing a third-party mirror.
+	envProviders        = "GETMUSIC_PROVIDERS"
+	defaultProviderName = "tidal-binimum"
+)
+
+// ProviderRegistry holds the configured upstream providers in priority
+// order and fans a search/resolve out across them until one succeeds.
+type ProviderRegistry struct {
+	providers []Provider
+	logger    *slog.Logger
+}
+
+// NewProviderRegistry builds the registry from GETMUSIC_PROVIDERS
+// ("name|searchURL|trackURL" entries separated by commas). With the env
+// var unset it falls back to the single historical tidal-api.binimum.org
+// endpoint; additional upstreams (mirrors or otherwise) are only ever
+// contacted if an operator opts in by setting GETMUSIC_PROVIDERS.
+func NewProviderRegistry(client *http.Client, logger *slog.Logger) *ProviderRegistry {
+	raw := os.Getenv(envProviders)
+	if raw == "" {
+		return &ProviderRegistry{providers: []Provider{
+			newTidalProvider(defaultProviderName, client, searchAPIURL, trackAPIURL),
+		}, logger: logger}
+	}
+
+	var providers []Provider
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.Split(entry, "|")
+		if len(parts) != 3 {
+			continue
+		}
+		providers = append(providers, newTidalProvider(strings.TrimSpace(parts[0]), client, strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])))
+	}
+
+	if len(providers) == 0 {
+		providers = append(providers, newTidalProvider(defaultProviderName, client, searchAPIURL, trackAPIURL))
+	}
+
+	return &ProviderRegistry{providers: providers, logger: logger}
+}
+
+// Search tries each provider in turn, stamping the winning provider's name
+// onto every returned TrackItem so a later Resolve knows where to send the
+// lookup. A "not found" result is treated as authoritative and is not
+// retried against the rest; only transport/upstream failures fall through.
+func (pr *ProviderRegistry) Search(ctx context.Context, query string) ([]TrackItem, error) {
+	var lastErr error
+	for _, p := range pr.providers {
+		tracks, err := p.Search(ctx, query)
+		if err == nil {
+			for i := range tracks {
+				tracks[i].Provider = p.Name()
+			}
+			return tracks, nil
+		}
+		lastErr = err
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// ResolveOn resolves against the named provider only.
+func (pr *ProviderRegistry) ResolveOn(ctx context.Context, providerName string, id int, quality string) (string, error) {
+	for _, p := range pr.providers {
+		if p.Name() == providerName {
+			return p.Resolve(ctx, id, quality)
+		}
+	}
+	return "", &statusError{http.StatusInternalServerError, fmt.Errorf("unknown provider %q", providerName)}
+}
+
+// Resolve tries every provider in order, for callers with a bare track ID
+// and no recorded provider of origin (e.g. the Subsonic surface). With
+// GETMUSIC_PROVIDERS configuring more than one provider, the same bare ID
+// can exist in more than one provider's independent catalog and resolve to
+// an unrelated track; a non-first hit is logged so that case is visible
+// instead of silently serving the wrong track.
+func (pr *ProviderRegistry) Resolve(ctx context.Context, id int, quality string) (string, error) {
+	var lastErr error
+	for i, p := range pr.providers {
+		u, err := p.Resolve(ctx, id, quality)
+		if err == nil {
+			if i > 0 {
+				pr.logger.Warn("Bare track ID resolved on a non-primary provider", "provider", p.Name(), "track_id", id)
+			}
+			return u, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
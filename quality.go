@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// qualityOrder ranks Tidal's audioQuality tokens from best to worst, used
+// to pick a deterministic winner instead of whichever resolve goroutine
+// happens to finish first.
+var qualityOrder = []string{"LOSSLESS", "HIGH", "LOW"}
+
+func normalizeQuality(q string) string {
+	return strings.ToUpper(q)
+}
+
+func qualityRank(q string) int {
+	q = normalizeQuality(q)
+	for i, v := range qualityOrder {
+		if v == q {
+			return i
+		}
+	}
+	return len(qualityOrder)
+}
+
+// parseQualityPreference reads ?quality=lossless|high|low|best, defaulting
+// to "best" for anything missing or unrecognized.
+func parseQualityPreference(r *http.Request) string {
+	switch q := normalizeQuality(r.URL.Query().Get("quality")); q {
+	case "LOSSLESS", "HIGH", "LOW":
+		return q
+	default:
+		return "BEST"
+	}
+}
+
+func wantsAllQualities(r *http.Request) bool {
+	return r.URL.Query().Get("all") == "1"
+}
+
+// resolveAllQualities fans out to every candidate track like
+// findFirstValidTrackURL, but waits for all of them instead of cancelling
+// on the first hit, so every quality tier that resolves successfully is
+// available to rank and choose from.
+func (app *App) resolveAllQualities(ctx context.Context, tracks []TrackItem, log *slog.Logger) (map[string]string, error) {
+	results := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i, track := range tracks {
+		wg.Add(1)
+		go func(track TrackItem, attempt int) {
+			defer wg.Done()
+			trackLog := log.With(slog.Int("track_id", track.ID), slog.Int("attempt", attempt))
+
+			finalURL, err := app.getTrackURL(ctx, track)
+			if err != nil {
+				var statusErr *statusError
+				if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+					trackLog.Warn("Track details not found (404), this goroutine will exit")
+				} else {
+					trackLog.Error("Unexpected error getting track URL", "error", err)
+				}
+				return
+			}
+
+			quality := normalizeQuality(track.AudioQuality)
+			mu.Lock()
+			if _, exists := results[quality]; !exists {
+				results[quality] = finalURL
+			}
+			mu.Unlock()
+		}(track, i+1)
+	}
+
+	wg.Wait()
+
+	if len(results) == 0 {
+		log.Warn("Processed all search results but found no valid track URL")
+		return nil, &statusError{http.StatusNotFound, errors.New("track not found")}
+	}
+	return results, nil
+}
+
+// bestQuality picks the highest-ranked entry out of a resolved quality map.
+func bestQuality(results map[string]string) (string, string) {
+	bestRank := len(qualityOrder) + 1
+	var bestQ, bestURL string
+	for q, u := range results {
+		if rank := qualityRank(q); rank < bestRank {
+			bestRank = rank
+			bestQ = q
+			bestURL = u
+		}
+	}
+	return bestQ, bestURL
+}
+
+// qualityAwareSearchHandler backs /search/{query} whenever ?quality= or
+// ?all=1 is present, picking the requested tier (or returning all of them)
+// from resolveAllQualities's results. The cache key folds in the quality
+// preference so it doesn't collide with a plain /search/{query} lookup or
+// a different preference for the same query.
+func (app *App) qualityAwareSearchHandler(w http.ResponseWriter, r *http.Request, query string, log *slog.Logger) {
+	pref := parseQualityPreference(r)
+	all := wantsAllQualities(r)
+	cacheKey := query + "|quality=" + pref + "|all=" + strconv.FormatBool(all)
+
+	if cached, found := app.cache.Get(cacheKey); found {
+		var resp FinalResponse
+		if err := json.Unmarshal([]byte(cached), &resp); err == nil {
+			log.Info("Cache hit")
+			app.jsonResponse(w, resp, http.StatusOK)
+			return
+		}
+	}
+	log.Info("Cache miss")
+
+	v, err, _ := app.sf.Do(cacheKey, func() (interface{}, error) {
+		tracks, err := app.searchTracks(r.Context(), query)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := app.resolveAllQualities(r.Context(), tracks, log)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp FinalResponse
+		switch {
+		case all:
+			resp = FinalResponse{Qualities: results}
+		case pref == "BEST":
+			q, u := bestQuality(results)
+			resp = FinalResponse{URL: u, Quality: q}
+		default:
+			u, ok := results[pref]
+			if !ok {
+				return nil, &statusError{http.StatusNotFound, fmt.Errorf("quality %q not available for this track", pref)}
+			}
+			resp = FinalResponse{URL: u, Quality: pref}
+		}
+
+		encoded, err := json.Marshal(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cached response: %w", err)
+		}
+		app.cache.Set(cacheKey, string(encoded), cacheTTL)
+		log.Info("Result cached", "ttl", cacheTTL.String())
+		return resp, nil
+	})
+
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			log.Warn("Failed to find track URL", "error", statusErr.Error())
+			app.jsonError(w, statusErr.Error(), statusErr.Code)
+		} else {
+			log.Error("Internal error during singleflight execution", "error", err)
+			app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.jsonResponse(w, v.(FinalResponse), http.StatusOK)
+}
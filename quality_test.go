@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestQualityRank(t *testing.T) {
+	cases := []struct {
+		quality string
+		want    int
+	}{
+		{"LOSSLESS", 0},
+		{"lossless", 0},
+		{"HIGH", 1},
+		{"LOW", 2},
+		{"UNKNOWN", len(qualityOrder)},
+	}
+
+	for _, c := range cases {
+		if got := qualityRank(c.quality); got != c.want {
+			t.Errorf("qualityRank(%q) = %d, want %d", c.quality, got, c.want)
+		}
+	}
+}
+
+func TestBestQuality(t *testing.T) {
+	results := map[string]string{
+		"HIGH":     "url-high",
+		"LOSSLESS": "url-lossless",
+		"LOW":      "url-low",
+	}
+
+	q, u := bestQuality(results)
+	if q != "LOSSLESS" || u != "url-lossless" {
+		t.Fatalf("bestQuality() = (%q, %q), want (LOSSLESS, url-lossless)", q, u)
+	}
+}
+
+func TestBestQualityRanksUnknownTiersLast(t *testing.T) {
+	results := map[string]string{
+		"WEIRD": "url-weird",
+		"HIGH":  "url-high",
+	}
+
+	q, u := bestQuality(results)
+	if q != "HIGH" || u != "url-high" {
+		t.Fatalf("bestQuality() = (%q, %q), want (HIGH, url-high)", q, u)
+	}
+}
+
+func TestParseQualityPreference(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{"", "BEST"},
+		{"best", "BEST"},
+		{"lossless", "LOSSLESS"},
+		{"HIGH", "HIGH"},
+		{"low", "LOW"},
+		{"garbage", "BEST"},
+	}
+
+	for _, c := range cases {
+		r := &http.Request{URL: &url.URL{RawQuery: url.Values{"quality": []string{c.raw}}.Encode()}}
+		if c.raw == "" {
+			r = &http.Request{URL: &url.URL{}}
+		}
+		if got := parseQualityPreference(r); got != c.want {
+			t.Errorf("parseQualityPreference(quality=%q) = %q, want %q", c.raw, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	defaultStreamFormat  = "mp3"
+	defaultStreamBitrate = "192"
+	ffmpegTimeout        = 3 * time.Minute
+)
+
+var streamContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"flac": "audio/flac",
+}
+
+func streamFormat(r *http.Request) string {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if _, ok := streamContentTypes[format]; !ok {
+		return defaultStreamFormat
+	}
+	return format
+}
+
+func streamBitrate(r *http.Request) string {
+	if b := r.URL.Query().Get("bitrate"); b != "" {
+		if _, err := strconv.Atoi(b); err == nil {
+			return b
+		}
+	}
+	return defaultStreamBitrate
+}
+
+// ffmpegArgs builds an ffmpeg invocation that reads the resolved upstream
+// URL directly (ffmpeg demuxes http(s) input fine on its own) and writes
+// the requested format to output, which is either "pipe:1" for a live
+// stream or a temp file path for the seekable Range path. For http(s)
+// input, -rw_timeout bounds a stalled read the same way app.client's
+// httpTimeout bounds a stalled plain request.
+func ffmpegArgs(sourceURL, format, bitrate, output string) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-y"}
+	if strings.HasPrefix(sourceURL, "http") {
+		args = append(args, "-reconnect", "1", "-reconnect_streamed", "1",
+			"-rw_timeout", strconv.FormatInt(httpTimeout.Microseconds(), 10))
+	}
+	args = append(args, "-i", sourceURL, "-vn", "-b:a", bitrate+"k")
+
+	switch format {
+	case "opus":
+		args = append(args, "-f", "opus")
+	case "flac":
+		args = append(args, "-f", "flac")
+	default:
+		args = append(args, "-f", "mp3")
+	}
+
+	return append(args, output)
+}
+
+// streamHandler resolves the query with the same search and fan-out as
+// searchHandler, but without its cache or singleflight, then transcodes the
+// upstream audio through ffmpeg instead of handing back a bare URL.
+func (app *App) streamHandler(w http.ResponseWriter, r *http.Request) {
+	query := chi.URLParam(r, "query")
+	if query == "" {
+		app.jsonError(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+
+	log := app.logger.With(slog.String("query", query), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+	tracks, err := app.searchTracks(r.Context(), query)
+	if err != nil {
+		app.writeStreamError(w, log, err)
+		return
+	}
+
+	sourceURL, err := app.findFirstValidTrackURL(r.Context(), tracks, log)
+	if err != nil {
+		app.writeStreamError(w, log, err)
+		return
+	}
+
+	app.transcodeAndServe(w, r, log, sourceURL)
+}
+
+// streamByIDHandler skips the search step for a client that already knows
+// the track ID, resolving it the same way the Subsonic surface does.
+func (app *App) streamByIDHandler(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		app.jsonError(w, "id must be numeric", http.StatusBadRequest)
+		return
+	}
+
+	log := app.logger.With(slog.Int("track_id", id), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+	quality := r.URL.Query().Get("quality")
+	if quality == "" {
+		quality = "LOSSLESS"
+	}
+
+	sourceURL, err := app.getTrackURL(r.Context(), TrackItem{ID: id, AudioQuality: quality})
+	if err != nil {
+		app.writeStreamError(w, log, err)
+		return
+	}
+
+	app.transcodeAndServe(w, r, log, sourceURL)
+}
+
+func (app *App) writeStreamError(w http.ResponseWriter, log *slog.Logger, err error) {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		log.Warn("Stream resolve failed", "error", statusErr.Error())
+		app.jsonError(w, statusErr.Error(), statusErr.Code)
+		return
+	}
+	log.Error("Stream resolve failed", "error", err)
+	app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// transcodeAndServe pipes the resolved upstream audio through ffmpeg and
+// streams the result with chunked encoding. A Range request falls back to
+// transcoding into a temp file first, since a live pipe can't be seeked.
+func (app *App) transcodeAndServe(w http.ResponseWriter, r *http.Request, log *slog.Logger, sourceURL string) {
+	format := streamFormat(r)
+	bitrate := streamBitrate(r)
+	contentType := streamContentTypes[format]
+
+	if r.Header.Get("Range") != "" {
+		app.serveTranscodedRange(w, r, log, sourceURL, format, bitrate, contentType)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), ffmpegTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs(sourceURL, format, bitrate, "pipe:1")...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Error("Failed to open ffmpeg stdout pipe", "error", err)
+		app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Error("Failed to start ffmpeg", "error", err)
+		app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, stdout); err != nil {
+		log.Warn("Transcode stream copy ended early", "error", err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		log.Warn("ffmpeg exited with error", "error", err)
+	}
+}
+
+func (app *App) serveTranscodedRange(w http.ResponseWriter, r *http.Request, log *slog.Logger, sourceURL, format, bitrate, contentType string) {
+	tmpFile, err := os.CreateTemp("", "getmusic-stream-*."+format)
+	if err != nil {
+		log.Error("Failed to create temp file for ranged transcode", "error", err)
+		app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	ctx, cancel := context.WithTimeout(r.Context(), ffmpegTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs(sourceURL, format, bitrate, tmpPath)...)
+	if err := cmd.Run(); err != nil {
+		log.Error("ffmpeg transcode to temp file failed", "error", err)
+		app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	out, err := os.Open(tmpPath)
+	if err != nil {
+		log.Error("Failed to reopen transcoded temp file", "error", err)
+		app.jsonError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer out.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, filepath.Base(tmpPath), time.Now(), out)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	subsonicAPIVersion = "1.16.1"
+	subsonicXMLNS      = "http://subsonic.org/restapi"
+)
+
+type subsonicError struct {
+	XMLName xml.Name `xml:"error" json:"-"`
+	Code    int      `xml:"code,attr" json:"code"`
+	Message string   `xml:"message,attr" json:"message"`
+}
+
+type subsonicSong struct {
+	XMLName xml.Name `xml:"song" json:"-"`
+	ID      string   `xml:"id,attr" json:"id"`
+	Title   string   `xml:"title,attr" json:"title"`
+	IsDir   bool     `xml:"isDir,attr" json:"isDir"`
+}
+
+type subsonicSearchResult3 struct {
+	XMLName xml.Name       `xml:"searchResult3" json:"-"`
+	Songs   []subsonicSong `xml:"song" json:"song,omitempty"`
+}
+
+type subsonicResponse struct {
+	XMLName       xml.Name               `xml:"subsonic-response" json:"-"`
+	Xmlns         string                 `xml:"xmlns,attr" json:"-"`
+	Status        string                 `xml:"status,attr" json:"status"`
+	Version       string                 `xml:"version,attr" json:"version"`
+	Error         *subsonicError         `xml:"error,omitempty" json:"error,omitempty"`
+	SearchResult3 *subsonicSearchResult3 `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Song          *subsonicSong          `xml:"song,omitempty" json:"song,omitempty"`
+}
+
+// subsonicEnvelope mirrors the "subsonic-response" wrapper real servers use
+// for f=json; the XML form relies on subsonicResponse.XMLName instead.
+type subsonicEnvelope struct {
+	Response subsonicResponse `json:"subsonic-response"`
+}
+
+// newSubsonicResponse is the base every response is built from, so the
+// xmlns stricter clients validate is never forgotten on a new branch.
+func newSubsonicResponse() subsonicResponse {
+	return subsonicResponse{Status: "ok", Version: subsonicAPIVersion, Xmlns: subsonicXMLNS}
+}
+
+func newSubsonicOK() subsonicResponse {
+	return newSubsonicResponse()
+}
+
+func newSubsonicError(code int, message string) subsonicResponse {
+	resp := newSubsonicResponse()
+	resp.Status = "failed"
+	resp.Error = &subsonicError{Code: code, Message: message}
+	return resp
+}
+
+func (app *App) writeSubsonic(w http.ResponseWriter, r *http.Request, resp subsonicResponse) {
+	if strings.EqualFold(r.URL.Query().Get("f"), "json") {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(subsonicEnvelope{Response: resp})
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(resp)
+}
+
+func (app *App) subsonicPingHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeSubsonic(w, r, newSubsonicOK())
+}
+
+// subsonicSearch3Handler maps Subsonic's id3-style search onto searchTracks.
+// The upstream search API only ever gives us an id and audioQuality per
+// hit, with no title/artist of its own, so each song's title is built from
+// the query plus its quality tier and position -- enough for a client to
+// tell hits apart in a list, then resolve one via getSong/stream.
+func (app *App) subsonicSearch3Handler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		app.writeSubsonic(w, r, newSubsonicError(10, "required parameter 'query' is missing"))
+		return
+	}
+
+	log := app.logger.With(slog.String("query", query), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+	tracks, err := app.searchTracks(r.Context(), query)
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+			resp := newSubsonicResponse()
+			resp.SearchResult3 = &subsonicSearchResult3{}
+			app.writeSubsonic(w, r, resp)
+			return
+		}
+		log.Error("Subsonic search3 failed", "error", err)
+		app.writeSubsonic(w, r, newSubsonicError(0, "search failed"))
+		return
+	}
+
+	songs := make([]subsonicSong, 0, len(tracks))
+	for i, track := range tracks {
+		title := fmt.Sprintf("%s (%s #%d)", query, strings.ToUpper(track.AudioQuality), i+1)
+		songs = append(songs, subsonicSong{ID: strconv.Itoa(track.ID), Title: title})
+	}
+
+	resp := newSubsonicResponse()
+	resp.SearchResult3 = &subsonicSearchResult3{Songs: songs}
+	app.writeSubsonic(w, r, resp)
+}
+
+func (app *App) parseSubsonicTrackID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idParam := r.URL.Query().Get("id")
+	if idParam == "" {
+		app.writeSubsonic(w, r, newSubsonicError(10, "required parameter 'id' is missing"))
+		return 0, false
+	}
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		app.writeSubsonic(w, r, newSubsonicError(0, "id must be numeric"))
+		return 0, false
+	}
+	return id, true
+}
+
+func subsonicQuality(r *http.Request) string {
+	if q := r.URL.Query().Get("quality"); q != "" {
+		return q
+	}
+	return "LOSSLESS"
+}
+
+func (app *App) subsonicGetSongHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.parseSubsonicTrackID(w, r)
+	if !ok {
+		return
+	}
+	resp := newSubsonicResponse()
+	resp.Song = &subsonicSong{ID: strconv.Itoa(id), Title: fmt.Sprintf("Track %d", id)}
+	app.writeSubsonic(w, r, resp)
+}
+
+// subsonicStreamHandler resolves the track straight through getTrackURL and
+// redirects the client to the OriginalTrackUrl.
+func (app *App) subsonicStreamHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.parseSubsonicTrackID(w, r)
+	if !ok {
+		return
+	}
+	log := app.logger.With(slog.Int("track_id", id), slog.String("request_id", middleware.GetReqID(r.Context())))
+
+	finalURL, err := app.getTrackURL(r.Context(), TrackItem{ID: id, AudioQuality: subsonicQuality(r)})
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			log.Warn("Subsonic stream resolve failed", "error", statusErr.Error())
+			app.writeSubsonic(w, r, newSubsonicError(70, "track not found"))
+			return
+		}
+		log.Error("Subsonic stream resolve failed", "error", err)
+		app.writeSubsonic(w, r, newSubsonicError(0, "internal error"))
+		return
+	}
+
+	http.Redirect(w, r, finalURL, http.StatusFound)
+}
+
+// subsonicDownloadHandler behaves identically to stream.view; getmusic has
+// no local storage to tell "play" and "download" apart.
+func (app *App) subsonicDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	app.subsonicStreamHandler(w, r)
+}